@@ -0,0 +1,445 @@
+// Package config holds the on-disk configuration format for
+// alertmanager-ntfy and the template machinery shared by the server.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/selector"
+)
+
+// Config is the root of the alertmanager-ntfy configuration file.
+type Config struct {
+	Ntfy *Ntfy `yaml:"ntfy"`
+}
+
+// Ntfy holds everything related to how notifications are pushed to an ntfy
+// server.
+type Ntfy struct {
+	URL          string       `yaml:"url"`
+	Topic        string       `yaml:"topic"`
+	Notification Notification `yaml:"notification"`
+}
+
+// Notification controls how an incoming alert is turned into an ntfy
+// notification.
+type Notification struct {
+	Templates *Templates `yaml:"templates"`
+
+	// LabelSelector, when set, limits notifications to alerts whose labels
+	// it matches. Alerts that don't match are dropped before any template
+	// is rendered.
+	LabelSelector *selector.Selector `yaml:"labelSelector"`
+}
+
+// Templates groups the set of templates used to render the different parts
+// of an ntfy notification, plus a set of named sub-templates that those
+// templates can pull in via the `include` function.
+type Templates struct {
+	Title   *Template
+	Message *Template
+	Tags    *Template
+	Labels  *Template
+
+	// Named holds the `named:` sub-templates, keyed by name, that the
+	// title/message/tags/labels templates can render with
+	// `{{ include "name" . }}`.
+	Named map[string]*Template
+
+	// Strict makes rendering fail instead of silently printing
+	// "<no value>" when a template references a label or annotation key
+	// that isn't set on the alert.
+	Strict bool
+
+	// mu guards reads of the fields above against the concurrent field
+	// swap a file watcher performs when `watch: true` is set and one of
+	// the backing template files changes.
+	mu sync.Mutex
+}
+
+// TemplateSet is a consistent, point-in-time snapshot of a Templates'
+// fields, safe to read even while a background file watcher is swapping
+// them out concurrently.
+type TemplateSet struct {
+	Title   *Template
+	Message *Template
+	Tags    *Template
+	Labels  *Template
+	Named   map[string]*Template
+	Strict  bool
+}
+
+// Current returns a snapshot of t's fields. Callers that render templates
+// should use this instead of reading t's fields directly, since a
+// `watch: true` template set may have its fields swapped out from another
+// goroutine at any time.
+func (t *Templates) Current() TemplateSet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TemplateSet{
+		Title:   t.Title,
+		Message: t.Message,
+		Tags:    t.Tags,
+		Labels:  t.Labels,
+		Named:   t.Named,
+		Strict:  t.Strict,
+	}
+}
+
+// templateFieldYAML is a template field that can be given either as an
+// inline string or, to load it from disk, as a `{file: path}` mapping.
+type templateFieldYAML struct {
+	Inline string
+	File   string
+}
+
+func (f *templateFieldYAML) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&f.Inline)
+	}
+
+	var raw struct {
+		File string `yaml:"file"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	f.File = raw.File
+	return nil
+}
+
+// text returns the field's template source, reading it from disk when File
+// is set.
+func (f templateFieldYAML) text() (string, error) {
+	if f.File == "" {
+		return f.Inline, nil
+	}
+
+	data, err := os.ReadFile(f.File)
+	if err != nil {
+		return "", fmt.Errorf("reading template file %s: %w", f.File, err)
+	}
+	return string(data), nil
+}
+
+// templatesYAML mirrors the YAML shape of Templates before its fields are
+// resolved (reading any file: sources) and parsed into associated
+// templates.
+type templatesYAML struct {
+	Title   templateFieldYAML            `yaml:"title"`
+	Message templateFieldYAML            `yaml:"message"`
+	Tags    templateFieldYAML            `yaml:"tags"`
+	Labels  templateFieldYAML            `yaml:"labels"`
+	Named   map[string]templateFieldYAML `yaml:"named"`
+
+	// Files loads a directory of named partials: each matched file becomes
+	// a named template, keyed by its base name with the extension removed.
+	Files []string `yaml:"files"`
+
+	Strict bool `yaml:"strict"`
+
+	// Watch re-parses the templates whenever one of their backing files
+	// changes on disk, instead of requiring a restart.
+	Watch bool `yaml:"watch"`
+}
+
+// UnmarshalYAML parses all of a Templates' fields into a single associated
+// template set, so that named sub-templates are reachable from the main
+// templates via `include`. When raw.Watch is set, it also starts a
+// background file watcher that keeps the Templates up to date.
+func (t *Templates) UnmarshalYAML(node *yaml.Node) error {
+	var raw templatesYAML
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	parsed, err := parseTemplates(raw)
+	if err != nil {
+		return err
+	}
+	t.Title, t.Message, t.Tags, t.Labels, t.Named, t.Strict =
+		parsed.Title, parsed.Message, parsed.Tags, parsed.Labels, parsed.Named, parsed.Strict
+
+	if raw.Watch {
+		if err := t.watch(raw); err != nil {
+			return fmt.Errorf("watching templates: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseTemplates resolves and parses every field of raw into a fresh,
+// associated template set.
+func parseTemplates(raw templatesYAML) (*Templates, error) {
+	root := template.New("root").Funcs(TemplateFuncs())
+	if raw.Strict {
+		root = root.Option("missingkey=error")
+	}
+
+	named := make(map[string]string, len(raw.Named))
+	for name, f := range raw.Named {
+		text, err := f.text()
+		if err != nil {
+			return nil, err
+		}
+		named[name] = text
+	}
+	for _, pattern := range raw.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expanding files pattern %q: %w", pattern, err)
+		}
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading template file %s: %w", path, err)
+			}
+			named[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = string(data)
+		}
+	}
+	for name, body := range named {
+		if _, err := root.New(name).Parse(body); err != nil {
+			return nil, CleanTemplateError(err)
+		}
+	}
+
+	t := &Templates{Strict: raw.Strict}
+	if len(named) > 0 {
+		t.Named = make(map[string]*Template, len(named))
+		for name := range named {
+			t.Named[name] = (*Template)(root.Lookup(name))
+		}
+	}
+
+	fields := []struct {
+		name string
+		src  templateFieldYAML
+		dst  **Template
+	}{
+		{"title", raw.Title, &t.Title},
+		{"message", raw.Message, &t.Message},
+		{"tags", raw.Tags, &t.Tags},
+		{"labels", raw.Labels, &t.Labels},
+	}
+	for _, f := range fields {
+		text, err := f.src.text()
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			continue
+		}
+		tmpl, err := root.New(f.name).Parse(text)
+		if err != nil {
+			return nil, CleanTemplateError(err)
+		}
+		*f.dst = (*Template)(tmpl)
+	}
+
+	return t, nil
+}
+
+// watch starts an fsnotify watcher over every file backing raw's fields and
+// partials. Whenever one of them changes, it re-parses the whole template
+// set with parseTemplates and swaps the result in, so that a render that's
+// already in flight keeps using the templates it started with.
+func (t *Templates) watch(raw templatesYAML) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]struct{}{}
+	addSource := func(path string) {
+		if path != "" {
+			dirs[filepath.Dir(path)] = struct{}{}
+		}
+	}
+	addSource(raw.Title.File)
+	addSource(raw.Message.File)
+	addSource(raw.Tags.File)
+	addSource(raw.Labels.File)
+	for _, f := range raw.Named {
+		addSource(f.File)
+	}
+	for _, pattern := range raw.Files {
+		addSource(pattern)
+	}
+
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watching directory %s: %w", dir, err)
+		}
+	}
+
+	go func() {
+		for event := range watcher.Events {
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			parsed, err := parseTemplates(raw)
+			if err != nil {
+				// Keep serving the last good template set rather than
+				// fail notifications over a config typo.
+				continue
+			}
+
+			t.mu.Lock()
+			t.Title, t.Message, t.Tags, t.Labels, t.Named, t.Strict =
+				parsed.Title, parsed.Message, parsed.Tags, parsed.Labels, parsed.Named, parsed.Strict
+			t.mu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// Template is a parsed text/template.Template. Its fields are never decoded
+// directly from YAML - Templates.UnmarshalYAML resolves and parses every
+// field itself via parseTemplates, so that Strict and the named
+// sub-templates are applied consistently regardless of which field is being
+// parsed.
+type Template template.Template
+
+// templateErrorLocation matches the location Go's text/template embeds in
+// its own error strings, e.g. `template: title:2:9: executing "title" at
+// <.Labels.severity>: map has no entry for key "severity"`.
+var templateErrorLocation = regexp.MustCompile(`^template: ([^:]+):(\d+)(?::(\d+))?: (?:executing "[^"]*" at <[^>]*>: )?(.*)$`)
+
+// CleanTemplateError rewrites the verbose errors text/template produces for
+// parse and execution failures into `template <name>:<line>[:<col>]:
+// <reason>`, stripping the `template:` prefix and the
+// `executing "x" at <y>:` noise so a bad config template points straight at
+// the offending line.
+func CleanTemplateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	match := templateErrorLocation.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	name, line, col := match[1], match[2], match[3]
+	reason := strings.TrimSpace(match[4])
+	if col == "" {
+		return fmt.Errorf("template %s:%s: %s", name, line, reason)
+	}
+	return fmt.Errorf("template %s:%s:%s: %s", name, line, col, reason)
+}
+
+// TemplateFuncs returns the function map made available to every template in
+// the configuration. It is built on top of Sprig's TxtFuncMap, the same
+// library Helm uses for its chart templates, so label/notification
+// templates get access to helpers like default, hasPrefix, regexMatch,
+// replace, quote, dict, list and the date functions. env/expandenv are
+// removed since template input can come from untrusted alert payloads.
+//
+// The handful of custom helpers the original, pre-Sprig implementation
+// shipped (split, trim, contains, upper, lower) are kept so existing
+// configs keep working unchanged.
+//
+// include and tpl are registered here only so that parsing a template
+// referencing them succeeds - their real implementations need the fully
+// parsed template set they're called from, so the server rebinds them to
+// working versions right before executing a template. required needs no
+// such context and works as-is.
+func TemplateFuncs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	funcs["split"] = splitFunc
+	funcs["trim"] = strings.TrimSpace
+	funcs["contains"] = strings.Contains
+	funcs["upper"] = strings.ToUpper
+	funcs["lower"] = strings.ToLower
+
+	funcs["required"] = requiredFunc
+	funcs["include"] = unboundIncludeFunc
+	funcs["tpl"] = unboundTplFunc
+	funcs["matches"] = matchesFunc
+
+	return funcs
+}
+
+// matchesFunc implements the `matches` template function: it parses expr as
+// a Kubernetes-style label selector (e.g. "severity in (critical,warning)")
+// and reports whether dot's labels satisfy it. dot may be a label map
+// directly (as in a `labels` template) or anything with an exported Labels
+// map[string]string field (as in `title`/`message`/`tags`, where dot is the
+// alert itself) - config deliberately doesn't import the alertmanager
+// package, so the latter is read via reflection rather than a concrete type.
+func matchesFunc(expr string, dot interface{}) (bool, error) {
+	sel, err := selector.Parse(expr)
+	if err != nil {
+		return false, err
+	}
+
+	labels, err := labelsOf(dot)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels), nil
+}
+
+// labelsOf extracts a label map out of dot, which is either a
+// map[string]string or a (possibly pointer-to-)struct with an exported
+// Labels field of that type.
+func labelsOf(dot interface{}) (map[string]string, error) {
+	if labels, ok := dot.(map[string]string); ok {
+		return labels, nil
+	}
+
+	v := reflect.ValueOf(dot)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Labels"); f.IsValid() {
+			if labels, ok := f.Interface().(map[string]string); ok {
+				return labels, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("matches: cannot read labels from %T", dot)
+}
+
+// requiredFunc implements the `required` template function: it returns an
+// error carrying msg when val is nil or the empty string, aborting the
+// render.
+func requiredFunc(msg string, val interface{}) (interface{}, error) {
+	if val == nil || val == "" {
+		return nil, errors.New(msg)
+	}
+	return val, nil
+}
+
+func unboundIncludeFunc(name string, data interface{}) (string, error) {
+	return "", fmt.Errorf("include %q: called outside of template rendering", name)
+}
+
+func unboundTplFunc(text string, data interface{}) (string, error) {
+	return "", errors.New("tpl: called outside of template rendering")
+}
+
+// splitFunc is the original custom `split` helper: split(value, sep). It
+// takes precedence over Sprig's own `split`, which has a different
+// signature and return type, so that existing configs don't break.
+func splitFunc(value, sep string) []string {
+	return strings.Split(value, sep)
+}
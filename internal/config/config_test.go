@@ -0,0 +1,95 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTemplatesStrictMissingKey(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      strict: true
+      title: '{{ .Labels.severity }}'
+`), &cfg)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	var buf strings.Builder
+	err = (*template.Template)(cfg.Ntfy.Notification.Templates.Title).Execute(&buf, struct{ Labels map[string]string }{})
+	if err == nil {
+		t.Fatal("expected missing-key error in strict mode, got none")
+	}
+}
+
+func TestTemplatesNonStrictMissingKey(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      title: '{{ .Labels.severity }}'
+`), &cfg)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	var buf strings.Builder
+	err = (*template.Template)(cfg.Ntfy.Notification.Templates.Title).Execute(&buf, struct{ Labels map[string]string }{})
+	if err != nil {
+		t.Fatalf("unexpected error outside of strict mode: %v", err)
+	}
+}
+
+func TestCleanTemplateErrorParse(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      title: '{{ .Labels. }}'
+`), &cfg)
+	if err == nil {
+		t.Fatal("expected a parse error, got none")
+	}
+	if strings.HasPrefix(err.Error(), "template: ") {
+		t.Errorf("expected the template: prefix to be stripped, got: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "template title:") {
+		t.Errorf("expected error to start with the template name and line, got: %v", err)
+	}
+}
+
+func TestCleanTemplateErrorExecution(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      strict: true
+      title: '{{ .Labels.severity }}'
+`), &cfg)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	var buf strings.Builder
+	execErr := (*template.Template)(cfg.Ntfy.Notification.Templates.Title).Execute(&buf, struct{ Labels map[string]string }{})
+	if execErr == nil {
+		t.Fatal("expected an execution error, got none")
+	}
+
+	cleaned := CleanTemplateError(execErr)
+	if strings.HasPrefix(cleaned.Error(), "template: ") {
+		t.Errorf("expected the template: prefix to be stripped, got: %v", cleaned)
+	}
+	if !strings.HasPrefix(cleaned.Error(), "template title:") {
+		t.Errorf("expected error to start with the template name and line, got: %v", cleaned)
+	}
+}
@@ -0,0 +1,196 @@
+// Package selector implements Kubernetes-style label selectors: the
+// matchLabels/matchExpressions structure used in Kubernetes API objects,
+// plus a parser for the compact selector string format
+// (`key=value,other in (a,b),!absent`) used in `kubectl --selector` and
+// alertmanager-ntfy's `matches` template function.
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Operator is a label selector requirement's comparison.
+type Operator string
+
+const (
+	In           Operator = "In"
+	NotIn        Operator = "NotIn"
+	Exists       Operator = "Exists"
+	DoesNotExist Operator = "DoesNotExist"
+)
+
+// Requirement is a single label selector condition.
+type Requirement struct {
+	Key      string   `yaml:"key"`
+	Operator Operator `yaml:"operator"`
+	Values   []string `yaml:"values"`
+}
+
+// Matches reports whether labels satisfies the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	val, ok := labels[r.Key]
+	switch r.Operator {
+	case In:
+		return ok && containsString(r.Values, val)
+	case NotIn:
+		return !ok || !containsString(r.Values, val)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+// Selector is a compiled Kubernetes-style label selector: matchLabels
+// entries must match exactly, and every matchExpressions requirement must
+// be satisfied. An empty Selector matches everything.
+type Selector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels"`
+	MatchExpressions []Requirement     `yaml:"matchExpressions"`
+}
+
+// Matches reports whether labels satisfies every matchLabels entry and
+// matchExpressions requirement in s.
+func (s *Selector) Matches(labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+
+	for key, val := range s.MatchLabels {
+		if labels[key] != val {
+			return false
+		}
+	}
+	for _, req := range s.MatchExpressions {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+var keyPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_./-]*[a-zA-Z0-9])?$`)
+
+var inNotInPattern = regexp.MustCompile(`^(.+?)\s+(in|notin)\s*\((.*)\)$`)
+
+// Parse parses a selector in the kubectl --selector string format, e.g.
+// `severity in (critical,warning),env=prod,!silenced`. Requirements are
+// combined with a logical AND. An empty or whitespace-only string parses to
+// a Selector that matches everything.
+func Parse(raw string) (*Selector, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return &Selector{}, nil
+	}
+
+	sel := &Selector{}
+	for _, part := range splitTopLevel(raw) {
+		part = strings.TrimSpace(part)
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		sel.MatchExpressions = append(sel.MatchExpressions, req)
+	}
+	return sel, nil
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	if s == "" {
+		return Requirement{}, fmt.Errorf("selector: empty requirement")
+	}
+
+	if strings.HasPrefix(s, "!") {
+		key := strings.TrimSpace(strings.TrimPrefix(s, "!"))
+		if !keyPattern.MatchString(key) {
+			return Requirement{}, fmt.Errorf("selector: invalid key %q", key)
+		}
+		return Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+
+	if m := inNotInPattern.FindStringSubmatch(s); m != nil {
+		key := strings.TrimSpace(m[1])
+		if !keyPattern.MatchString(key) {
+			return Requirement{}, fmt.Errorf("selector: invalid key %q", key)
+		}
+		op := In
+		if m[2] == "notin" {
+			op = NotIn
+		}
+		return Requirement{Key: key, Operator: op, Values: splitValues(m[3])}, nil
+	}
+
+	if key, val, ok := cutOperator(s, "!="); ok {
+		return Requirement{Key: key, Operator: NotIn, Values: []string{val}}, nil
+	}
+	if key, val, ok := cutOperator(s, "=="); ok {
+		return Requirement{Key: key, Operator: In, Values: []string{val}}, nil
+	}
+	if key, val, ok := cutOperator(s, "="); ok {
+		return Requirement{Key: key, Operator: In, Values: []string{val}}, nil
+	}
+
+	if !keyPattern.MatchString(s) {
+		return Requirement{}, fmt.Errorf("selector: invalid requirement %q", s)
+	}
+	return Requirement{Key: s, Operator: Exists}, nil
+}
+
+func cutOperator(s, op string) (key, val string, ok bool) {
+	idx := strings.Index(s, op)
+	if idx < 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(s[:idx])
+	val = strings.TrimSpace(s[idx+len(op):])
+	if !keyPattern.MatchString(key) {
+		return "", "", false
+	}
+	return key, val, true
+}
+
+// splitTopLevel splits s on commas that aren't nested inside parentheses,
+// so `a in (b,c),d=e` splits into ["a in (b,c)", "d=e"].
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
+
+func splitValues(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    *Selector
+		wantErr bool
+	}{
+		{
+			name: "empty selector matches everything",
+			expr: "",
+			want: &Selector{},
+		},
+		{
+			name: "equality",
+			expr: "severity=critical",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: In, Values: []string{"critical"}}}},
+		},
+		{
+			name: "double equals equality",
+			expr: "severity==critical",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: In, Values: []string{"critical"}}}},
+		},
+		{
+			name: "inequality",
+			expr: "severity!=info",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: NotIn, Values: []string{"info"}}}},
+		},
+		{
+			name: "exists",
+			expr: "severity",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: Exists}}},
+		},
+		{
+			name: "does not exist",
+			expr: "!silenced",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "silenced", Operator: DoesNotExist}}},
+		},
+		{
+			name: "in",
+			expr: "severity in (critical,warning)",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: In, Values: []string{"critical", "warning"}}}},
+		},
+		{
+			name: "notin",
+			expr: "severity notin (info, debug)",
+			want: &Selector{MatchExpressions: []Requirement{{Key: "severity", Operator: NotIn, Values: []string{"info", "debug"}}}},
+		},
+		{
+			name: "multiple requirements combine with AND",
+			expr: "severity in (critical,warning),env=prod,!silenced",
+			want: &Selector{MatchExpressions: []Requirement{
+				{Key: "severity", Operator: In, Values: []string{"critical", "warning"}},
+				{Key: "env", Operator: In, Values: []string{"prod"}},
+				{Key: "silenced", Operator: DoesNotExist},
+			}},
+		},
+		{
+			name:    "invalid key",
+			expr:    "!!!bad",
+			wantErr: true,
+		},
+		{
+			name:    "empty requirement",
+			expr:    "severity=critical,,env=prod",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		sel    *Selector
+		labels map[string]string
+		want   bool
+	}{
+		{
+			name:   "nil selector matches everything",
+			sel:    nil,
+			labels: map[string]string{"severity": "critical"},
+			want:   true,
+		},
+		{
+			name:   "matchLabels exact match",
+			sel:    &Selector{MatchLabels: map[string]string{"severity": "critical"}},
+			labels: map[string]string{"severity": "critical", "service": "api"},
+			want:   true,
+		},
+		{
+			name:   "matchLabels mismatch",
+			sel:    &Selector{MatchLabels: map[string]string{"severity": "critical"}},
+			labels: map[string]string{"severity": "warning"},
+			want:   false,
+		},
+		{
+			name: "matchExpressions In",
+			sel: &Selector{MatchExpressions: []Requirement{
+				{Key: "severity", Operator: In, Values: []string{"critical", "warning"}},
+			}},
+			labels: map[string]string{"severity": "warning"},
+			want:   true,
+		},
+		{
+			name: "matchExpressions NotIn matches missing label too",
+			sel: &Selector{MatchExpressions: []Requirement{
+				{Key: "severity", Operator: NotIn, Values: []string{"critical"}},
+			}},
+			labels: map[string]string{"service": "api"},
+			want:   true,
+		},
+		{
+			name: "matchExpressions Exists",
+			sel: &Selector{MatchExpressions: []Requirement{
+				{Key: "severity", Operator: Exists},
+			}},
+			labels: map[string]string{"service": "api"},
+			want:   false,
+		},
+		{
+			name: "matchLabels and matchExpressions are ANDed",
+			sel: &Selector{
+				MatchLabels:      map[string]string{"env": "prod"},
+				MatchExpressions: []Requirement{{Key: "severity", Operator: In, Values: []string{"critical"}}},
+			},
+			labels: map[string]string{"env": "prod", "severity": "warning"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sel.Matches(tt.labels); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
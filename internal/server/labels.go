@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
+	"github.com/alexbakker/alertmanager-ntfy/internal/config"
+)
+
+// renderLabelsTemplate turns an alert's labels into the list of tags
+// attached to the resulting ntfy notification.
+//
+// Without a configured template, each label is rendered as "key = value".
+// When the configured template's body is nothing but a bare range over its
+// root value (e.g. `{{range $key, $value := .}}...{{end}}`), it is executed
+// once per label so that every iteration produces its own tag - this lets a
+// template written for a single label double as a per-label formatter. Any
+// other template is executed once against the full label set and the
+// output is split into tags on commas if it contains any, or otherwise on
+// whitespace, so a template that separates tags with ", " and one that
+// separates them with plain spaces both work.
+//
+// The whitespace fallback can't distinguish "no separator was written"
+// from "a tag value legitimately contains a space": a template that emits
+// e.g. `env=us east` (no comma) is split into the two tags "env=us" and
+// "east" rather than the one intended tag. Templates whose values may
+// contain spaces should join tags with "," explicitly to avoid this.
+func (s *Server) renderLabelsTemplate(alert *alertmanager.Alert) ([]string, error) {
+	set := s.cfg.Ntfy.Notification.Templates.Current()
+	if set.Labels == nil {
+		keys := sortedKeys(alert.Labels)
+		tags := make([]string, 0, len(keys))
+		for _, k := range keys {
+			tags = append(tags, fmt.Sprintf("%s = %s", k, alert.Labels[k]))
+		}
+		return tags, nil
+	}
+
+	tmpl := bindLateFuncs((*template.Template)(set.Labels), set.Strict)
+
+	if isRangeOverDot(tmpl) {
+		var tags []string
+		for _, k := range sortedKeys(alert.Labels) {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, map[string]string{k: alert.Labels[k]}); err != nil {
+				return nil, config.CleanTemplateError(err)
+			}
+			if tag := strings.TrimSpace(buf.String()); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		return tags, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, alert.Labels); err != nil {
+		return nil, config.CleanTemplateError(err)
+	}
+
+	parts := strings.Fields(buf.String())
+	if strings.Contains(buf.String(), ",") {
+		parts = strings.Split(buf.String(), ",")
+	}
+
+	var tags []string
+	for _, part := range parts {
+		if tag := strings.TrimSpace(part); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// isRangeOverDot reports whether tmpl's body is nothing but a single range
+// action iterating directly over its root value, e.g.
+// `{{range $k, $v := .}}...{{end}}`.
+func isRangeOverDot(tmpl *template.Template) bool {
+	if tmpl == nil || tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return false
+	}
+
+	var rng *parse.RangeNode
+	for _, n := range tmpl.Tree.Root.Nodes {
+		switch v := n.(type) {
+		case *parse.TextNode:
+			if strings.TrimSpace(string(v.Text)) != "" {
+				return false
+			}
+		case *parse.RangeNode:
+			if rng != nil {
+				return false
+			}
+			rng = v
+		default:
+			return false
+		}
+	}
+	if rng == nil || len(rng.Pipe.Decl) != 2 || len(rng.Pipe.Cmds) != 1 {
+		return false
+	}
+
+	cmd := rng.Pipe.Cmds[0]
+	if len(cmd.Args) != 1 {
+		return false
+	}
+	_, ok := cmd.Args[0].(*parse.DotNode)
+	return ok
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
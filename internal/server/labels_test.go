@@ -1,9 +1,14 @@
 package server
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
 
 	"github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
 	"github.com/alexbakker/alertmanager-ntfy/internal/config"
@@ -11,53 +16,53 @@ import (
 
 func TestRenderLabelsTemplate(t *testing.T) {
 	tests := []struct {
-		name           string
-		templateStr    string
-		labels         map[string]string
-		expectedTags   []string
-		expectError    bool
+		name         string
+		templateStr  string
+		labels       map[string]string
+		expectedTags []string
+		expectError  bool
 	}{
 		{
-			name:        "nil template uses default behavior",
-			templateStr: "",
-			labels:      map[string]string{"severity": "critical", "service": "api"},
+			name:         "nil template uses default behavior",
+			templateStr:  "",
+			labels:       map[string]string{"severity": "critical", "service": "api"},
 			expectedTags: []string{"severity = critical", "service = api"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
-			name:        "empty template returns no tags",
-			templateStr: "{{/* empty template */}}",
-			labels:      map[string]string{"severity": "critical"},
+			name:         "empty template returns no tags",
+			templateStr:  "{{/* empty template */}}",
+			labels:       map[string]string{"severity": "critical"},
 			expectedTags: []string{},
-			expectError: false,
+			expectError:  false,
 		},
 		{
-			name:        "custom format with colons",
-			templateStr: "{{range $key, $value := .}}{{$key}}: {{$value}}{{end}}",
-			labels:      map[string]string{"severity": "critical", "service": "api"},
+			name:         "custom format with colons",
+			templateStr:  "{{range $key, $value := .}}{{$key}}: {{$value}}{{end}}",
+			labels:       map[string]string{"severity": "critical", "service": "api"},
 			expectedTags: []string{"severity: critical", "service: api"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
-			name:        "split function test",
-			templateStr: "{{- $items := split (index . \"list\") \",\" -}}{{- range $i, $item := $items -}}{{- if $i }},{{ end -}}{{ trim $item }}{{- end -}}",
-			labels:      map[string]string{"list": "item1, item2 , item3"},
+			name:         "split function test",
+			templateStr:  "{{- $items := split (index . \"list\") \",\" -}}{{- range $i, $item := $items -}}{{- if $i }},{{ end -}}{{ trim $item }}{{- end -}}",
+			labels:       map[string]string{"list": "item1, item2 , item3"},
 			expectedTags: []string{"item1", "item2", "item3"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
-			name:        "conditional template",
-			templateStr: "{{range $key, $value := .}}{{if ne $key \"internal\"}}{{$key}}={{$value}}{{end}}{{end}}",
-			labels:      map[string]string{"severity": "critical", "internal": "debug", "service": "api"},
+			name:         "conditional template",
+			templateStr:  "{{range $key, $value := .}}{{if ne $key \"internal\"}}{{$key}}={{$value}}{{end}}{{end}}",
+			labels:       map[string]string{"severity": "critical", "internal": "debug", "service": "api"},
 			expectedTags: []string{"severity=critical", "service=api"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
-			name:        "custom functions test",
-			templateStr: "{{range $key, $value := .}}{{if contains $key \"env\"}}{{ upper $key }}={{ lower $value }}{{end}}{{end}}",
-			labels:      map[string]string{"environment": "PRODUCTION", "severity": "critical"},
+			name:         "custom functions test",
+			templateStr:  "{{range $key, $value := .}}{{if contains $key \"env\"}}{{ upper $key }}={{ lower $value }}{{end}}{{end}}",
+			labels:       map[string]string{"environment": "PRODUCTION", "severity": "critical"},
 			expectedTags: []string{"ENVIRONMENT=production"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
 			name: "show_labels with uppercase values",
@@ -76,9 +81,9 @@ func TestRenderLabelsTemplate(t *testing.T) {
     {{- if ne $key "show_labels" -}}{{ $key }}={{ $value }} {{ end -}}
   {{- end -}}
 {{- end -}}`,
-			labels:      map[string]string{"show_labels": "severity,service", "severity": "critical", "service": "api", "internal": "debug"},
+			labels:       map[string]string{"show_labels": "severity,service", "severity": "critical", "service": "api", "internal": "debug"},
 			expectedTags: []string{"severity=CRITICAL", "service=API"},
-			expectError: false,
+			expectError:  false,
 		},
 		{
 			name: "show_labels not set, uses default behavior",
@@ -97,9 +102,48 @@ func TestRenderLabelsTemplate(t *testing.T) {
     {{- if ne $key "show_labels" -}}{{ $key }}={{ $value }} {{ end -}}
   {{- end -}}
 {{- end -}}`,
-			labels:      map[string]string{"severity": "critical", "service": "api"},
-			expectedTags: []string{"severity=critical ", "service=api "},
-			expectError: false,
+			labels:       map[string]string{"severity": "critical", "service": "api"},
+			expectedTags: []string{"severity=critical", "service=api"},
+			expectError:  false,
+		},
+		{
+			name:         "sprig default function test",
+			templateStr:  `{{range $key, $value := .}}{{$key}}={{default "unknown" $value}}{{end}}`,
+			labels:       map[string]string{"severity": ""},
+			expectedTags: []string{"severity=unknown"},
+			expectError:  false,
+		},
+		{
+			name:         "sprig hasPrefix and hasSuffix functions test",
+			templateStr:  `{{range $key, $value := .}}{{if and (hasPrefix "api" $key) (hasSuffix "prod" $value)}}{{$key}}={{$value}}{{end}}{{end}}`,
+			labels:       map[string]string{"api-gateway": "us-east-prod", "database": "us-east-prod"},
+			expectedTags: []string{"api-gateway=us-east-prod"},
+			expectError:  false,
+		},
+		{
+			name:         "sprig regexMatch function test",
+			templateStr:  `{{range $key, $value := .}}{{if regexMatch "^[0-9]+$" $value}}{{$key}}={{$value}}{{end}}{{end}}`,
+			labels:       map[string]string{"retries": "3", "service": "api"},
+			expectedTags: []string{"retries=3"},
+			expectError:  false,
+		},
+		{
+			name:         "sprig replace and quote functions test",
+			templateStr:  `{{range $key, $value := .}}{{$key}}={{quote (replace "_" "-" $value)}}{{end}}`,
+			labels:       map[string]string{"service": "order_api"},
+			expectedTags: []string{`service="order-api"`},
+			expectError:  false,
+		},
+		{
+			// Pins a known, documented limitation: a single-output template
+			// with no comma falls back to splitting on whitespace, which
+			// can't tell a tag-separating space from one that's part of the
+			// value itself.
+			name:         "whitespace fallback splits a value that contains a space (documented limitation)",
+			templateStr:  `env={{ index . "env" }}`,
+			labels:       map[string]string{"env": "us east"},
+			expectedTags: []string{"env=us", "east"},
+			expectError:  false,
 		},
 	}
 
@@ -155,3 +199,54 @@ func TestRenderLabelsTemplate(t *testing.T) {
 		})
 	}
 }
+
+// TestRenderLabelsTemplateFileWatch checks that a labels template loaded
+// from a `file:` source with `watch: true` picks up edits made to that file
+// on disk without the config being re-read.
+func TestRenderLabelsTemplateFileWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "labels.tmpl")
+	if err := os.WriteFile(path, []byte(`{{range $k, $v := .}}{{$k}}={{$v}}{{end}}`), 0o644); err != nil {
+		t.Fatalf("failed to write template file: %v", err)
+	}
+
+	var cfg config.Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      labels:
+        file: `+path+`
+      watch: true
+`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse test config: %v", err)
+	}
+
+	server := &Server{cfg: &cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{"severity": "critical"}}
+
+	tags, err := server.renderLabelsTemplate(alert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "severity=critical" {
+		t.Fatalf("expected [severity=critical], got %v", tags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{{range $k, $v := .}}{{$k}}!{{$v}}{{end}}`), 0o644); err != nil {
+		t.Fatalf("failed to rewrite template file: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tags, err := server.renderLabelsTemplate(alert)
+		if err == nil && len(tags) == 1 && tags[0] == "severity!critical" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("template was not reloaded in time, last tags: %v, err: %v", tags, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import "github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
+
+// Notification is the rendered, ready-to-push representation of an alert.
+type Notification struct {
+	Title   string
+	Message string
+	Tags    []string
+}
+
+// renderNotification renders alert into a Notification. It returns a nil
+// Notification, with no error, if the alert is filtered out by the
+// configured label selector - in that case none of the alert's templates
+// are rendered.
+func (s *Server) renderNotification(alert *alertmanager.Alert) (*Notification, error) {
+	if !s.matchesLabelSelector(alert) {
+		return nil, nil
+	}
+
+	tags, err := s.renderLabelsTemplate(alert)
+	if err != nil {
+		return nil, err
+	}
+
+	title, err := s.renderTitleTemplate(alert)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.renderMessageTemplate(alert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Notification{Title: title, Message: message, Tags: tags}, nil
+}
+
+// matchesLabelSelector reports whether alert's labels satisfy the
+// configured label selector. An unconfigured selector matches every alert.
+func (s *Server) matchesLabelSelector(alert *alertmanager.Alert) bool {
+	return s.cfg.Ntfy.Notification.LabelSelector.Matches(alert.Labels)
+}
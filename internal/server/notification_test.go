@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
+	"github.com/alexbakker/alertmanager-ntfy/internal/config"
+)
+
+func TestRenderNotificationLabelSelectorFiltersAlert(t *testing.T) {
+	var cfg config.Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    labelSelector:
+      matchLabels:
+        severity: critical
+    templates:
+      labels: '{{ required "severity must be set" .severity }}'
+`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse test config: %v", err)
+	}
+
+	server := &Server{cfg: &cfg}
+
+	matching := &alertmanager.Alert{Labels: map[string]string{"severity": "critical"}}
+	n, err := server.renderNotification(matching)
+	if err != nil {
+		t.Fatalf("unexpected error for matching alert: %v", err)
+	}
+	if n == nil {
+		t.Fatal("expected a notification for a matching alert, got nil")
+	}
+
+	// This alert doesn't have the required "severity" label, so the labels
+	// template would fail if it were rendered - the label selector must
+	// filter it out first.
+	nonMatching := &alertmanager.Alert{Labels: map[string]string{"service": "api"}}
+	n, err = server.renderNotification(nonMatching)
+	if err != nil {
+		t.Fatalf("unexpected error for filtered alert: %v", err)
+	}
+	if n != nil {
+		t.Fatalf("expected a filtered alert to render to nil, got %+v", n)
+	}
+}
+
+func TestRenderNotificationNoLabelSelectorMatchesEverything(t *testing.T) {
+	var cfg config.Config
+	err := yaml.Unmarshal([]byte(`
+ntfy:
+  notification:
+    templates:
+      labels: '{{ range $k, $v := . }}{{ $k }}={{ $v }}{{ end }}'
+`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse test config: %v", err)
+	}
+
+	server := &Server{cfg: &cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{"severity": "info"}}
+
+	n, err := server.renderNotification(alert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n == nil {
+		t.Fatal("expected a notification when no label selector is configured")
+	}
+}
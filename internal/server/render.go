@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
+	"github.com/alexbakker/alertmanager-ntfy/internal/config"
+)
+
+// includeRecursionLimit bounds how deeply `include` may call into itself
+// (directly or through a chain of named templates), mirroring the guard
+// Helm's engine applies to its own `include` function.
+const includeRecursionLimit = 100
+
+// renderTitleTemplate renders the notification title template, falling back
+// to the alert's "summary" annotation when no template is configured.
+func (s *Server) renderTitleTemplate(alert *alertmanager.Alert) (string, error) {
+	set := s.cfg.Ntfy.Notification.Templates.Current()
+	return s.renderTemplate(set.Title, set.Strict, alert, alert.Annotations["summary"])
+}
+
+// renderMessageTemplate renders the notification message template, falling
+// back to the alert's "description" annotation when no template is
+// configured.
+func (s *Server) renderMessageTemplate(alert *alertmanager.Alert) (string, error) {
+	set := s.cfg.Ntfy.Notification.Templates.Current()
+	return s.renderTemplate(set.Message, set.Strict, alert, alert.Annotations["description"])
+}
+
+func (s *Server) renderTemplate(tmplField *config.Template, strict bool, alert *alertmanager.Alert, fallback string) (string, error) {
+	if tmplField == nil {
+		return fallback, nil
+	}
+
+	tmpl := bindLateFuncs((*template.Template)(tmplField), strict)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, alert); err != nil {
+		return "", config.CleanTemplateError(err)
+	}
+	return buf.String(), nil
+}
+
+// bindLateFuncs rebinds the `include` and `tpl` functions installed by
+// config.TemplateFuncs() to working implementations backed by tmpl's
+// associated template set. This has to happen here rather than in
+// config.TemplateFuncs() because those functions need the fully parsed
+// template - named sub-templates included - to call into, and that only
+// exists once config has finished parsing.
+//
+// tmpl is cloned first so that binding funcs on it (which text/template
+// implements by mutating the template's shared func map) doesn't race with
+// a concurrent render of the same config.Template, and so each render gets
+// its own include recursion counter. template.Template.Clone does not carry
+// over the parser's Option settings, so strict re-applies
+// "missingkey=error" on the clone (and on tpl's further clone) when the
+// template set it came from has Strict enabled - otherwise a strict config
+// would silently stop enforcing missing keys the moment a template went
+// through bindLateFuncs.
+func bindLateFuncs(tmpl *template.Template, strict bool) *template.Template {
+	if cloned, err := tmpl.Clone(); err == nil {
+		tmpl = cloned
+	}
+	if strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+
+	depth := 0
+
+	include := func(name string, data interface{}) (string, error) {
+		depth++
+		defer func() { depth-- }()
+		if depth > includeRecursionLimit {
+			return "", fmt.Errorf("include %q: recursion limit reached (max %d)", name, includeRecursionLimit)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	tpl := func(text string, data interface{}) (string, error) {
+		t, err := tmpl.Clone()
+		if err != nil {
+			return "", fmt.Errorf("tpl: %w", err)
+		}
+		if strict {
+			t = t.Option("missingkey=error")
+		}
+		if t, err = t.New("tpl").Parse(text); err != nil {
+			return "", fmt.Errorf("tpl: %w", err)
+		}
+
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, "tpl", data); err != nil {
+			return "", fmt.Errorf("tpl: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	return tmpl.Funcs(template.FuncMap{
+		"include": include,
+		"tpl":     tpl,
+	})
+}
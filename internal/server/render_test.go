@@ -0,0 +1,193 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/alertmanager"
+	"github.com/alexbakker/alertmanager-ntfy/internal/config"
+)
+
+func parseTestConfig(t *testing.T, yamlStr string) *config.Config {
+	t.Helper()
+
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(yamlStr), &cfg); err != nil {
+		t.Fatalf("failed to parse test config: %v", err)
+	}
+	return &cfg
+}
+
+func TestRenderTitleTemplateInclude(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      title: '{{ include "severityTag" . }}'
+      named:
+        severityTag: '[{{ upper .Labels.severity }}]'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{"severity": "critical"}}
+
+	title, err := server.renderTitleTemplate(alert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if title != "[CRITICAL]" {
+		t.Errorf("expected title %q, got %q", "[CRITICAL]", title)
+	}
+}
+
+func TestRenderTitleTemplateRequired(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      title: '{{ required "severity label is required" .Labels.severity }}'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{}}
+
+	if _, err := server.renderTitleTemplate(alert); err == nil {
+		t.Fatal("expected error for missing required label, got none")
+	} else if !strings.Contains(err.Error(), "severity label is required") {
+		t.Errorf("expected error to mention the required message, got: %v", err)
+	}
+}
+
+func TestRenderMessageTemplateTpl(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      message: '{{ tpl .Annotations.template . }}'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{
+		Labels:      map[string]string{"severity": "critical"},
+		Annotations: map[string]string{"template": "severity is {{ .Labels.severity }}"},
+	}
+
+	message, err := server.renderMessageTemplate(alert)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message != "severity is critical" {
+		t.Errorf("expected message %q, got %q", "severity is critical", message)
+	}
+}
+
+func TestRenderTitleTemplateIncludeRecursionLimit(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      title: '{{ include "selfRef" . }}'
+      named:
+        selfRef: '{{ include "selfRef" . }}'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{}}
+
+	_, err := server.renderTitleTemplate(alert)
+	if err == nil {
+		t.Fatal("expected recursion limit error, got none")
+	}
+	if !strings.Contains(err.Error(), "recursion limit") {
+		t.Errorf("expected error to mention the recursion limit, got: %v", err)
+	}
+}
+
+func TestRenderTitleTemplateMatches(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      title: '{{ if matches "severity in (critical,warning)" . }}alert{{ else }}ignore{{ end }}'
+`)
+
+	server := &Server{cfg: cfg}
+
+	critical := &alertmanager.Alert{Labels: map[string]string{"severity": "critical"}}
+	title, err := server.renderTitleTemplate(critical)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if title != "alert" {
+		t.Errorf("expected title %q, got %q", "alert", title)
+	}
+
+	info := &alertmanager.Alert{Labels: map[string]string{"severity": "info"}}
+	title, err = server.renderTitleTemplate(info)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if title != "ignore" {
+		t.Errorf("expected title %q, got %q", "ignore", title)
+	}
+}
+
+// TestRenderTitleTemplateStrictMissingKey guards against a regression where
+// bindLateFuncs cloned the template (Clone doesn't carry over Option
+// settings) without re-applying "missingkey=error", so a strict config's
+// missing-key check silently stopped firing on the server's actual render
+// path even though it still passed when executed directly in config tests.
+func TestRenderTitleTemplateStrictMissingKey(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      strict: true
+      title: '{{ .Labels.severity }}'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{}}
+
+	if _, err := server.renderTitleTemplate(alert); err == nil {
+		t.Fatal("expected missing-key error in strict mode, got none")
+	}
+}
+
+// TestRenderTitleTemplateIncludeConcurrent renders the same config.Template
+// from many goroutines at once. bindLateFuncs used to call tmpl.Funcs on the
+// shared template and close over a single depth counter, so concurrent
+// renders would race on both; run under -race to catch a regression.
+func TestRenderTitleTemplateIncludeConcurrent(t *testing.T) {
+	cfg := parseTestConfig(t, `
+ntfy:
+  notification:
+    templates:
+      title: '{{ include "severityTag" . }}'
+      named:
+        severityTag: '[{{ upper .Labels.severity }}]'
+`)
+
+	server := &Server{cfg: cfg}
+	alert := &alertmanager.Alert{Labels: map[string]string{"severity": "critical"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			title, err := server.renderTitleTemplate(alert)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if title != "[CRITICAL]" {
+				t.Errorf("expected title %q, got %q", "[CRITICAL]", title)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,24 @@
+// Package server renders incoming Alertmanager alerts into ntfy
+// notifications and delivers them to the configured ntfy instance.
+package server
+
+import (
+	"net/http"
+
+	"github.com/alexbakker/alertmanager-ntfy/internal/config"
+)
+
+// Server renders incoming Alertmanager alerts into ntfy notifications and
+// pushes them to the configured ntfy instance.
+type Server struct {
+	cfg        *config.Config
+	httpClient *http.Client
+}
+
+// New creates a Server for the given configuration.
+func New(cfg *config.Config) *Server {
+	return &Server{
+		cfg:        cfg,
+		httpClient: http.DefaultClient,
+	}
+}